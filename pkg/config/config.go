@@ -0,0 +1,110 @@
+// Package config contains the global configuration for the application
+package config
+
+import (
+	"sync"
+	"time"
+)
+
+// Config contains the global configuration for the application
+type Config struct {
+	// Bind is the address the app server listens on
+	Bind string
+	// Port is the port the app server listens on
+	Port int
+	// ListenAddresses, when set, takes precedence over Bind/Port and lets the app server listen on multiple
+	// addresses at once, e.g. "tcp://0.0.0.0:4181", "tcp://[::1]:4181" or "unix:///run/tfa/tfa.sock"
+	ListenAddresses []string
+
+	// MetricsBind is the address the metrics server listens on
+	MetricsBind string
+	// MetricsPort is the port the metrics server listens on
+	MetricsPort int
+	// MetricsListenAddresses, when set, takes precedence over MetricsBind/MetricsPort, with the same semantics
+	// as ListenAddresses
+	MetricsListenAddresses []string
+	// EnableMetrics controls whether the metrics server is started
+	EnableMetrics bool
+
+	// BasePath is the base path the app routes are served under
+	BasePath string
+	// Hostname is the public hostname this instance is reachable at
+	Hostname string
+
+	// TLSPath is the folder to look for TLS certificates/keys/CA in, when not provided as PEM values
+	TLSPath string
+	// TLSCertPEM is the PEM-encoded server certificate
+	TLSCertPEM string
+	// TLSKeyPEM is the PEM-encoded server key
+	TLSKeyPEM string
+	// TLSCAPEM is the PEM-encoded CA certificate bundle used for mTLS client authentication
+	TLSCAPEM string
+	// TLSClientAuth enables mTLS client authentication on sensitive endpoints
+	TLSClientAuth bool
+	// TLSCertificates configures one or more additional server certificates, selected by SNI host name
+	TLSCertificates []TLSCertificateEntry
+
+	// TLSCRLPath is the path to a CRL file used to reject revoked client certificates
+	TLSCRLPath string
+	// TLSCRLPEM is a list of PEM-encoded CRLs, as an alternative to TLSCRLPath
+	TLSCRLPEM []string
+	// TLSOCSPStapling enables fetching and serving an OCSP staple for the server certificate
+	TLSOCSPStapling bool
+
+	// TLSMinVersion and TLSMaxVersion set the negotiable TLS protocol floor/ceiling, e.g. "1.2" or "1.3"
+	TLSMinVersion string
+	TLSMaxVersion string
+	// TLSCipherSuites restricts the negotiable cipher suites to this list of IANA names, when set
+	TLSCipherSuites []string
+	// TLSCurvePreferences restricts the negotiable elliptic curves to this list of names, when set
+	TLSCurvePreferences []string
+	// TLSNextProtos restricts the negotiable ALPN protocols to this list, when set, instead of the default
+	// "h2", "http/1.1"
+	TLSNextProtos []string
+
+	// TLSExpiryWarnBefore is how far ahead of a certificate's expiry the "tls" readiness probe starts reporting
+	// degraded; defaults to 14 days when unset
+	TLSExpiryWarnBefore time.Duration
+
+	loadedConfigPath string
+}
+
+// TLSCertificateEntry is a single server certificate configured via `tlsCertificates`, selected by SNI host name
+type TLSCertificateEntry struct {
+	// SNINames are the SNI host names this entry should be served for; if empty, they're derived from the
+	// certificate's own DNS SANs
+	SNINames []string
+	// Default marks this entry as the fallback certificate when no other entry matches the requested SNI name
+	Default bool
+
+	// CertPEM and KeyPEM are a PEM-encoded cert/key pair; must be set together
+	CertPEM string
+	KeyPEM  string
+	// Path is a directory to load a reloadable cert/key pair from, as an alternative to CertPEM/KeyPEM
+	Path string
+}
+
+// GetLoadedConfigPath returns the path of the config file that was loaded, or an empty string if none was
+func (c *Config) GetLoadedConfigPath() string {
+	return c.loadedConfigPath
+}
+
+var (
+	current   *Config
+	currentMu sync.RWMutex
+)
+
+// Get returns the current global configuration
+func Get() *Config {
+	currentMu.RLock()
+	defer currentMu.RUnlock()
+	return current
+}
+
+// Set replaces the current global configuration
+// This is primarily used by tests and by the app's startup code
+func Set(cfg *Config) {
+	currentMu.Lock()
+	defer currentMu.Unlock()
+	current = cfg
+}
@@ -0,0 +1,93 @@
+// Package metrics contains the Prometheus metrics collected by the app
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// TFAMetrics is the interface implemented by the app's metrics collector
+type TFAMetrics interface {
+	// Init registers all metrics with the default Prometheus registry
+	Init()
+	// HTTPHandler returns the http.Handler that serves the /metrics endpoint
+	HTTPHandler() http.Handler
+
+	// RecordTLSCAReloadFailure increments the counter of failed trusted client CA pool (or CRL) reloads
+	RecordTLSCAReloadFailure()
+	// RecordTLSRevokedRejection increments the counter of handshakes rejected because the peer certificate was
+	// found on a CRL
+	RecordTLSRevokedRejection()
+	// RecordTLSOCSPRefreshFailure increments the counter of failed OCSP staple refreshes
+	RecordTLSOCSPRefreshFailure()
+
+	// RecordHealthCheck records the outcome of a single readiness probe run
+	RecordHealthCheck(name string, ok bool)
+}
+
+// TFAMetricsImpl is the default, Prometheus-backed implementation of TFAMetrics
+type TFAMetricsImpl struct {
+	tlsCAReloadFailures   prometheus.Counter
+	tlsRevokedRejections  prometheus.Counter
+	tlsOCSPRefreshFailure prometheus.Counter
+	healthChecks          *prometheus.CounterVec
+}
+
+// Init registers all metrics with the default Prometheus registry
+func (m *TFAMetricsImpl) Init() {
+	m.tlsCAReloadFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tfa_tls_ca_reload_failures_total",
+		Help: "Number of times reloading the trusted client CA pool or the CRLs from disk has failed",
+	})
+	m.tlsRevokedRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tfa_tls_revoked_rejections_total",
+		Help: "Number of mTLS handshakes rejected because the peer certificate was found on a CRL",
+	})
+	m.tlsOCSPRefreshFailure = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "tfa_tls_ocsp_refresh_failures_total",
+		Help: "Number of times refreshing the OCSP staple for the server certificate has failed",
+	})
+	m.healthChecks = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tfa_health_checks_total",
+		Help: "Number of readiness probe runs, by probe name and outcome",
+	}, []string{"name", "status"})
+
+	prometheus.MustRegister(
+		m.tlsCAReloadFailures,
+		m.tlsRevokedRejections,
+		m.tlsOCSPRefreshFailure,
+		m.healthChecks,
+	)
+}
+
+// HTTPHandler returns the http.Handler that serves the /metrics endpoint
+func (m *TFAMetricsImpl) HTTPHandler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RecordTLSCAReloadFailure increments the counter of failed trusted client CA pool (or CRL) reloads
+func (m *TFAMetricsImpl) RecordTLSCAReloadFailure() {
+	m.tlsCAReloadFailures.Inc()
+}
+
+// RecordTLSRevokedRejection increments the counter of handshakes rejected because the peer certificate was found
+// on a CRL
+func (m *TFAMetricsImpl) RecordTLSRevokedRejection() {
+	m.tlsRevokedRejections.Inc()
+}
+
+// RecordTLSOCSPRefreshFailure increments the counter of failed OCSP staple refreshes
+func (m *TFAMetricsImpl) RecordTLSOCSPRefreshFailure() {
+	m.tlsOCSPRefreshFailure.Inc()
+}
+
+// RecordHealthCheck records the outcome of a single readiness probe run
+func (m *TFAMetricsImpl) RecordHealthCheck(name string, ok bool) {
+	status := "ok"
+	if !ok {
+		status = "degraded"
+	}
+	m.healthChecks.WithLabelValues(name, status).Inc()
+}
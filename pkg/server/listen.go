@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+)
+
+// defaultUnixSocketMode is the file mode used for Unix domain socket listeners when none is specified
+const defaultUnixSocketMode = os.FileMode(0o660)
+
+// listenSpec describes a single listener to create, parsed from a listen URL such as
+// "tcp://0.0.0.0:4181", "tcp://[::1]:4181" or "unix:///run/tfa/tfa.sock"
+type listenSpec struct {
+	// raw is the original listen URL
+	raw string
+	// network is the listen-URL scheme: "tcp" or "unix" (passed to net.Listen as-is), "proxy+tcp" or "fd"
+	network string
+	// address is the host/port (e.g. "0.0.0.0:4181"), the socket path (e.g. "/run/tfa/tfa.sock"), or for "fd"
+	// the systemd file descriptor name
+	address string
+	// unixSocketMode is the file mode to apply after creating a Unix socket listener; unused for TCP
+	unixSocketMode os.FileMode
+}
+
+// parseListenSpec parses a listen URL into a listenSpec
+// Supported schemes are "tcp" and "unix" (the built-in networks), "proxy+tcp" (TCP wrapped in a PROXY protocol
+// decoder) and "fd" (systemd socket activation); a Unix socket's file mode can be overridden with a "mode" query
+// parameter, e.g. "unix:///run/tfa/tfa.sock?mode=0600"
+func parseListenSpec(raw string) (listenSpec, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return listenSpec{}, fmt.Errorf("invalid listen address '%s': %w", raw, err)
+	}
+
+	switch u.Scheme {
+	case "tcp", "proxy+tcp":
+		if u.Host == "" {
+			return listenSpec{}, fmt.Errorf("invalid listen address '%s': missing host/port", raw)
+		}
+		return listenSpec{raw: raw, network: u.Scheme, address: u.Host}, nil
+
+	case "unix":
+		path := u.Path
+		if path == "" {
+			return listenSpec{}, fmt.Errorf("invalid listen address '%s': missing socket path", raw)
+		}
+
+		mode := defaultUnixSocketMode
+		if m := u.Query().Get("mode"); m != "" {
+			parsed, mErr := strconv.ParseUint(m, 8, 32)
+			if mErr != nil {
+				return listenSpec{}, fmt.Errorf("invalid 'mode' query parameter in listen address '%s': %w", raw, mErr)
+			}
+			mode = os.FileMode(parsed)
+		}
+
+		return listenSpec{raw: raw, network: "unix", address: path, unixSocketMode: mode}, nil
+
+	case "fd":
+		name := u.Host
+		if name == "" {
+			return listenSpec{}, fmt.Errorf("invalid listen address '%s': missing file descriptor name", raw)
+		}
+		return listenSpec{raw: raw, network: "fd", address: name}, nil
+
+	default:
+		return listenSpec{}, fmt.Errorf("invalid listen address '%s': unsupported scheme '%s' (must be 'tcp', 'unix', 'proxy+tcp' or 'fd')", raw, u.Scheme)
+	}
+}
+
+// parseListenSpecs parses a list of listen URLs
+// If addresses is empty, it falls back to a single TCP listener built from bind and port, preserving the
+// behavior of the previous single-address configuration
+func parseListenSpecs(addresses []string, fallbackBind string, fallbackPort int) ([]listenSpec, error) {
+	if len(addresses) == 0 {
+		addresses = []string{fmt.Sprintf("tcp://%s", net.JoinHostPort(fallbackBind, strconv.Itoa(fallbackPort)))}
+	}
+
+	specs := make([]listenSpec, len(addresses))
+	for i, raw := range addresses {
+		spec, err := parseListenSpec(raw)
+		if err != nil {
+			return nil, err
+		}
+		specs[i] = spec
+	}
+
+	return specs, nil
+}
+
+// listen creates a net.Listener for the given spec, dispatching to the ListenerFactory registered for its scheme
+func listen(spec listenSpec) (net.Listener, error) {
+	factory, err := listenerFactoryForScheme(spec.network)
+	if err != nil {
+		return nil, err
+	}
+	return factory.Listen(spec)
+}
+
+// rawListen creates a plain TCP/Unix net.Listener for the given spec, without any PROXY protocol or systemd
+// wrapping; this is what tcpListenerFactory uses, and what proxyProtocolListenerFactory wraps
+// For Unix sockets, any stale socket file left over from a previous run is removed first, and the new socket
+// is chmod'd to the requested mode once created
+func rawListen(spec listenSpec) (net.Listener, error) {
+	if spec.network == "unix" {
+		// Remove a stale socket file from a previous, uncleanly terminated run
+		if err := os.Remove(spec.address); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to remove stale Unix socket '%s': %w", spec.address, err)
+		}
+	}
+
+	ln, err := net.Listen(spec.network, spec.address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s listener on '%s': %w", spec.network, spec.address, err)
+	}
+
+	if spec.network == "unix" {
+		if err = os.Chmod(spec.address, spec.unixSocketMode); err != nil {
+			ln.Close()
+			return nil, fmt.Errorf("failed to set mode on Unix socket '%s': %w", spec.address, err)
+		}
+	}
+
+	return ln, nil
+}
+
+// cleanup removes resources created for this listener that outlive the net.Listener itself, namely the Unix
+// socket file on disk
+func (spec listenSpec) cleanup() {
+	if spec.network == "unix" {
+		_ = os.Remove(spec.address)
+	}
+}
+
+// closePartialListeners closes every non-nil listener in listeners and runs cleanup() for its corresponding spec
+// This is used to unwind a batch of listeners already created earlier in the same call when a later one in the
+// list fails to bind, so we don't leak fds or leave stale Unix socket files behind
+func closePartialListeners(listeners []net.Listener, specs []listenSpec) {
+	for i, ln := range listeners {
+		if ln == nil {
+			continue
+		}
+		_ = ln.Close()
+		if i < len(specs) {
+			specs[i].cleanup()
+		}
+	}
+}
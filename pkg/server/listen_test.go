@@ -0,0 +1,58 @@
+package server
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseListenSpec(t *testing.T) {
+	tests := []struct {
+		raw         string
+		wantNetwork string
+		wantAddress string
+		wantMode    os.FileMode
+		wantErr     bool
+	}{
+		{raw: "tcp://0.0.0.0:4181", wantNetwork: "tcp", wantAddress: "0.0.0.0:4181"},
+		{raw: "tcp://[::1]:4181", wantNetwork: "tcp", wantAddress: "[::1]:4181"},
+		{raw: "proxy+tcp://0.0.0.0:4181", wantNetwork: "proxy+tcp", wantAddress: "0.0.0.0:4181"},
+		{raw: "unix:///run/tfa/tfa.sock", wantNetwork: "unix", wantAddress: "/run/tfa/tfa.sock", wantMode: defaultUnixSocketMode},
+		{raw: "unix:///run/tfa/tfa.sock?mode=0600", wantNetwork: "unix", wantAddress: "/run/tfa/tfa.sock", wantMode: 0o600},
+		{raw: "fd://tfa", wantNetwork: "fd", wantAddress: "tfa"},
+		{raw: "tcp://", wantErr: true},
+		{raw: "unix://", wantErr: true},
+		{raw: "fd://", wantErr: true},
+		{raw: "udp://0.0.0.0:4181", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		spec, err := parseListenSpec(tt.raw)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseListenSpec(%q): expected an error, got nil", tt.raw)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("parseListenSpec(%q): unexpected error: %v", tt.raw, err)
+			continue
+		}
+		if spec.network != tt.wantNetwork || spec.address != tt.wantAddress {
+			t.Errorf("parseListenSpec(%q) = {network: %q, address: %q}, want {%q, %q}",
+				tt.raw, spec.network, spec.address, tt.wantNetwork, tt.wantAddress)
+		}
+		if spec.network == "unix" && spec.unixSocketMode != tt.wantMode {
+			t.Errorf("parseListenSpec(%q): unixSocketMode = %v, want %v", tt.raw, spec.unixSocketMode, tt.wantMode)
+		}
+	}
+}
+
+func TestParseListenSpecsFallback(t *testing.T) {
+	specs, err := parseListenSpecs(nil, "0.0.0.0", 4181)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(specs) != 1 || specs[0].network != "tcp" || specs[0].address != "0.0.0.0:4181" {
+		t.Errorf("unexpected fallback spec: %+v", specs)
+	}
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// proxyProtocolHeaderTimeout bounds how long we wait to read a PROXY protocol header from a newly-accepted
+// connection, so a client that never sends one (or sends it one byte at a time) can't stall the listener
+const proxyProtocolHeaderTimeout = 5 * time.Second
+
+// ListenerFactory creates a net.Listener for a listenSpec
+// Built-in implementations cover plain TCP/Unix sockets (the default), PROXY protocol v1/v2 unwrapping for
+// deployments behind an L4 load balancer, and systemd socket activation for fd-inherited listeners
+type ListenerFactory interface {
+	Listen(spec listenSpec) (net.Listener, error)
+}
+
+// tcpListenerFactory is the default factory, used for the "tcp" and "unix" schemes
+type tcpListenerFactory struct{}
+
+func (tcpListenerFactory) Listen(spec listenSpec) (net.Listener, error) {
+	return rawListen(spec)
+}
+
+// listenerFactoryForScheme returns the ListenerFactory responsible for the given listen-URL scheme
+// The scheme also determines the "inner" network passed to the factory: "proxy+tcp" unwraps to "tcp" wrapped in
+// the PROXY protocol decoder, while "fd" is resolved via systemd socket activation
+func listenerFactoryForScheme(scheme string) (ListenerFactory, error) {
+	switch scheme {
+	case "tcp", "unix":
+		return tcpListenerFactory{}, nil
+	case "proxy+tcp":
+		return proxyProtocolListenerFactory{inner: tcpListenerFactory{}}, nil
+	case "fd":
+		return systemdListenerFactory{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported scheme '%s' (must be 'tcp', 'unix', 'proxy+tcp' or 'fd')", scheme)
+	}
+}
+
+// proxyProtocolListenerFactory wraps another factory's listener so every accepted connection is first decoded
+// as a PROXY protocol (v1 or v2) preamble, with RemoteAddr() overridden to the address reported by the proxy
+// rather than the L4 load balancer's own address
+type proxyProtocolListenerFactory struct {
+	inner ListenerFactory
+}
+
+func (f proxyProtocolListenerFactory) Listen(spec listenSpec) (net.Listener, error) {
+	// The inner factory only understands plain network schemes
+	tcpSpec := spec
+	tcpSpec.network = "tcp"
+
+	ln, err := f.inner.Listen(tcpSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &proxyProtoListener{Listener: ln}, nil
+}
+
+// proxyProtoListener decodes a PROXY protocol header from each accepted connection before handing it to callers
+type proxyProtoListener struct {
+	net.Listener
+}
+
+// Accept blocks until a connection is accepted and its PROXY protocol header successfully decoded
+// A connection that fails to send a valid header in time is closed and skipped rather than returned as an error:
+// http.Server.Serve treats any error from Accept that isn't a temporary net.Error as fatal and stops the whole
+// listener, so a single malformed or idle connection must never propagate past this point
+func (l *proxyProtoListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := conn.SetReadDeadline(time.Now().Add(proxyProtocolHeaderTimeout)); err != nil {
+			conn.Close()
+			continue
+		}
+
+		remoteAddr, br, err := decodeProxyProtocolHeader(conn)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+
+		if err := conn.SetReadDeadline(time.Time{}); err != nil {
+			conn.Close()
+			continue
+		}
+
+		return &proxyProtoConn{Conn: conn, reader: br, remoteAddr: remoteAddr}, nil
+	}
+}
+
+// proxyProtoConn wraps a net.Conn, serving any bytes buffered while decoding the PROXY header before falling
+// back to the underlying connection, and reporting the address extracted from that header
+type proxyProtoConn struct {
+	net.Conn
+	reader     *bufio.Reader
+	remoteAddr net.Addr
+}
+
+func (c *proxyProtoConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
+
+func (c *proxyProtoConn) RemoteAddr() net.Addr {
+	if c.remoteAddr != nil {
+		return c.remoteAddr
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// proxyProtoV2Signature is the fixed 12-byte signature at the start of every PROXY protocol v2 header
+var proxyProtoV2Signature = []byte{0x0D, 0x0A, 0x0D, 0x0A, 0x00, 0x0D, 0x0A, 0x51, 0x55, 0x49, 0x54, 0x0A}
+
+// decodeProxyProtocolHeader reads and parses a PROXY protocol v1 or v2 header from conn, returning the original
+// client address it reports and a bufio.Reader positioned right after the header (wrapping conn, so unread
+// buffered bytes are preserved for the caller)
+func decodeProxyProtocolHeader(conn net.Conn) (net.Addr, *bufio.Reader, error) {
+	br := bufio.NewReader(conn)
+
+	peek, err := br.Peek(len(proxyProtoV2Signature))
+	if err == nil && bytes.Equal(peek, proxyProtoV2Signature) {
+		return decodeProxyProtocolV2(br)
+	}
+
+	return decodeProxyProtocolV1(br)
+}
+
+// decodeProxyProtocolV1 parses the human-readable v1 header, e.g. "PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\n"
+func decodeProxyProtocolV1(br *bufio.Reader) (net.Addr, *bufio.Reader, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read PROXY v1 header line: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+
+	parts := strings.Fields(line)
+	if len(parts) < 2 || parts[0] != "PROXY" {
+		return nil, nil, fmt.Errorf("not a valid PROXY v1 header: '%s'", line)
+	}
+
+	if parts[1] == "UNKNOWN" {
+		return nil, br, nil
+	}
+
+	if len(parts) != 6 {
+		return nil, nil, fmt.Errorf("malformed PROXY v1 header: '%s'", line)
+	}
+
+	srcIP := parts[2]
+	srcPort, err := strconv.Atoi(parts[4])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid source port in PROXY v1 header: %w", err)
+	}
+
+	return &net.TCPAddr{IP: net.ParseIP(srcIP), Port: srcPort}, br, nil
+}
+
+// decodeProxyProtocolV2 parses the binary v2 header; only the source address of the first TLV-less header is
+// extracted, additional TLVs (if any) are skipped
+func decodeProxyProtocolV2(br *bufio.Reader) (net.Addr, *bufio.Reader, error) {
+	header := make([]byte, 16)
+	if _, err := readFull(br, header); err != nil {
+		return nil, nil, fmt.Errorf("failed to read PROXY v2 header: %w", err)
+	}
+
+	verCmd := header[12]
+	if verCmd>>4 != 2 {
+		return nil, nil, fmt.Errorf("unsupported PROXY protocol version %d", verCmd>>4)
+	}
+	family := header[13] >> 4
+	addrLen := int(header[14])<<8 | int(header[15])
+
+	body := make([]byte, addrLen)
+	if _, err := readFull(br, body); err != nil {
+		return nil, nil, fmt.Errorf("failed to read PROXY v2 address block: %w", err)
+	}
+
+	// LOCAL command (health checks from the LB itself) carries no meaningful address
+	if verCmd&0x0F == 0 {
+		return nil, br, nil
+	}
+
+	switch family {
+	case 1: // AF_INET
+		if len(body) < 12 {
+			return nil, nil, fmt.Errorf("truncated PROXY v2 IPv4 address block")
+		}
+		ip := net.IP(body[0:4])
+		port := int(body[8])<<8 | int(body[9])
+		return &net.TCPAddr{IP: ip, Port: port}, br, nil
+	case 2: // AF_INET6
+		if len(body) < 36 {
+			return nil, nil, fmt.Errorf("truncated PROXY v2 IPv6 address block")
+		}
+		ip := net.IP(body[0:16])
+		port := int(body[32])<<8 | int(body[33])
+		return &net.TCPAddr{IP: ip, Port: port}, br, nil
+	default:
+		// AF_UNSPEC or AF_UNIX: no routable address to report
+		return nil, br, nil
+	}
+}
+
+func readFull(br *bufio.Reader, buf []byte) (int, error) {
+	n := 0
+	for n < len(buf) {
+		m, err := br.Read(buf[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// systemdListenerFactory resolves a "fd://<name>" listen URL to a file descriptor inherited from systemd socket
+// activation, per the LISTEN_FDS/LISTEN_FDNAMES protocol (sd_listen_fds(3))
+type systemdListenerFactory struct{}
+
+// systemdListenFDsStart is the first inherited file descriptor number per the systemd socket activation protocol
+const systemdListenFDsStart = 3
+
+func (systemdListenerFactory) Listen(spec listenSpec) (net.Listener, error) {
+	name := spec.address
+
+	countStr := os.Getenv("LISTEN_FDS")
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return nil, fmt.Errorf("systemd socket activation requested ('fd://%s') but LISTEN_FDS is not set or invalid", name)
+	}
+
+	namesEnv := os.Getenv("LISTEN_FDNAMES")
+	names := strings.Split(namesEnv, ":")
+
+	idx := -1
+	for i := 0; i < count; i++ {
+		if i < len(names) && names[i] == name {
+			idx = i
+			break
+		}
+	}
+	// If the name isn't found (or wasn't set via FileDescriptorName=) and there's exactly one socket, use it
+	if idx == -1 && count == 1 {
+		idx = 0
+	}
+	if idx == -1 {
+		return nil, fmt.Errorf("no systemd socket named '%s' found among %d inherited file descriptor(s)", name, count)
+	}
+
+	file := os.NewFile(uintptr(systemdListenFDsStart+idx), name)
+	ln, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create listener from inherited file descriptor '%s': %w", name, err)
+	}
+
+	return ln, nil
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDecodeProxyProtocolV1(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("PROXY TCP4 192.0.2.1 192.0.2.2 56324 443\r\nhello"))
+
+	addr, out, err := decodeProxyProtocolV1(br)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		t.Fatalf("expected *net.TCPAddr, got %T", addr)
+	}
+	if tcpAddr.IP.String() != "192.0.2.1" || tcpAddr.Port != 56324 {
+		t.Errorf("unexpected address: %s:%d", tcpAddr.IP, tcpAddr.Port)
+	}
+
+	rest := make([]byte, 5)
+	if _, err := out.Read(rest); err != nil {
+		t.Fatalf("unexpected error reading remainder: %v", err)
+	}
+	if string(rest) != "hello" {
+		t.Errorf("expected buffered remainder 'hello', got %q", rest)
+	}
+}
+
+func TestDecodeProxyProtocolV1Unknown(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n"))
+
+	addr, _, err := decodeProxyProtocolV1(br)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr != nil {
+		t.Errorf("expected nil address for UNKNOWN, got %v", addr)
+	}
+}
+
+func TestDecodeProxyProtocolV1Malformed(t *testing.T) {
+	br := bufio.NewReader(strings.NewReader("NOT A PROXY HEADER\r\n"))
+
+	if _, _, err := decodeProxyProtocolV1(br); err == nil {
+		t.Error("expected an error for a malformed header, got nil")
+	}
+}
+
+func TestDecodeProxyProtocolV2(t *testing.T) {
+	header := append([]byte{}, proxyProtoV2Signature...)
+	header = append(header, 0x21, 0x11, 0x00, 0x0C)
+	header = append(header,
+		192, 0, 2, 1, // src addr
+		192, 0, 2, 2, // dst addr
+		0xDC, 0x04, // src port 56324
+		0x01, 0xBB, // dst port 443
+	)
+
+	br := bufio.NewReader(bytes.NewReader(header))
+	addr, _, err := decodeProxyProtocolV2(br)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		t.Fatalf("expected *net.TCPAddr, got %T", addr)
+	}
+	if tcpAddr.IP.String() != "192.0.2.1" || tcpAddr.Port != 56324 {
+		t.Errorf("unexpected address: %s:%d", tcpAddr.IP, tcpAddr.Port)
+	}
+}
+
+func TestDecodeProxyProtocolV2Local(t *testing.T) {
+	header := append([]byte{}, proxyProtoV2Signature...)
+	header = append(header, 0x20, 0x00, 0x00, 0x00)
+
+	br := bufio.NewReader(bytes.NewReader(header))
+	addr, _, err := decodeProxyProtocolV2(br)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if addr != nil {
+		t.Errorf("expected nil address for a LOCAL command, got %v", addr)
+	}
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/italypaleale/traefik-forward-auth/pkg/config"
+)
+
+// healthCheckTimeout bounds how long a single readiness probe is allowed to run before it's considered failed
+const healthCheckTimeout = 5 * time.Second
+
+// healthStatus is the outcome of a single readiness probe
+type healthStatus string
+
+const (
+	healthStatusOK       healthStatus = "ok"
+	healthStatusDegraded healthStatus = "degraded"
+)
+
+// healthProbe is a single named readiness check
+type healthProbe struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// healthCheckResult is the JSON representation of a single probe's outcome
+type healthCheckResult struct {
+	Status healthStatus `json:"status"`
+	Error  string       `json:"error,omitempty"`
+	// TookMs is only populated when the request includes "?verbose=1"
+	TookMs int64 `json:"tookMs,omitempty"`
+}
+
+// healthResponse is the JSON body returned by RouteReadyzHandler
+type healthResponse struct {
+	Status healthStatus                 `json:"status"`
+	Checks map[string]healthCheckResult `json:"checks"`
+}
+
+// RouteLivezHandler reports whether the process is alive, with no dependency checks
+// This corresponds to Kubernetes' liveness probe convention: if this doesn't respond, the process is restarted
+func (s *Server) RouteLivezHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// RouteReadyzHandler runs the registered health probes and reports whether the app is ready to serve traffic
+// This corresponds to Kubernetes' readiness probe convention: while this reports non-ok, the pod is removed
+// from service endpoints but not restarted
+// Pass "?verbose=1" to include per-probe timing in the response
+func (s *Server) RouteReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	verbose := r.URL.Query().Get("verbose") == "1"
+
+	resp := healthResponse{
+		Status: healthStatusOK,
+		Checks: make(map[string]healthCheckResult, len(s.healthProbes)),
+	}
+
+	for _, probe := range s.healthProbes {
+		ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+		start := time.Now()
+		err := probe.Check(ctx)
+		took := time.Since(start)
+		cancel()
+
+		result := healthCheckResult{Status: healthStatusOK}
+		if err != nil {
+			result.Status = healthStatusDegraded
+			result.Error = err.Error()
+			resp.Status = healthStatusDegraded
+		}
+		if verbose {
+			result.TookMs = took.Milliseconds()
+		}
+		resp.Checks[probe.Name] = result
+
+		s.metrics.RecordHealthCheck(probe.Name, err == nil)
+	}
+
+	statusCode := http.StatusOK
+	if resp.Status != healthStatusOK {
+		statusCode = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// initHealthProbes builds the registry of readiness probes run by RouteReadyzHandler
+func (s *Server) initHealthProbes(cfg *config.Config) {
+	s.healthProbes = []healthProbe{
+		{Name: "tls", Check: s.checkTLSExpiry},
+	}
+
+	if reachable, ok := s.auth.(interface {
+		CheckReachable(ctx context.Context) error
+	}); ok {
+		s.healthProbes = append(s.healthProbes, healthProbe{Name: "oidc", Check: reachable.CheckReachable})
+	}
+}
+
+// checkTLSExpiry fails the probe if the currently-served leaf certificate, or any CA in the trusted client pool,
+// is within cfg.TLSExpiryWarnBefore of expiring
+func (s *Server) checkTLSExpiry(_ context.Context) error {
+	if s.tlsConfig == nil {
+		// TLS isn't enabled; nothing to check
+		return nil
+	}
+
+	cfg := config.Get()
+	warnBefore := cfg.TLSExpiryWarnBefore
+	if warnBefore <= 0 {
+		warnBefore = 14 * 24 * time.Hour
+	}
+
+	deadline := time.Now().Add(warnBefore)
+
+	var leafDER []byte
+	if s.tlsConfig.GetCertificate != nil {
+		cert, err := s.tlsConfig.GetCertificate(nil)
+		if err == nil && cert != nil && len(cert.Certificate) > 0 {
+			leafDER = cert.Certificate[0]
+		}
+	} else if len(s.tlsConfig.Certificates) > 0 && len(s.tlsConfig.Certificates[0].Certificate) > 0 {
+		leafDER = s.tlsConfig.Certificates[0].Certificate[0]
+	}
+
+	if leafDER != nil {
+		leaf, err := x509.ParseCertificate(leafDER)
+		if err == nil && leaf.NotAfter.Before(deadline) {
+			return fmt.Errorf("certificate '%s' expires at %s", leaf.Subject.CommonName, leaf.NotAfter.Format(time.RFC3339))
+		}
+	}
+
+	// Also check every CA in the trusted client pool; an expired CA silently breaks mTLS for every client
+	// certificate it issued
+	if s.tlsCAProvider != nil {
+		for _, ca := range s.tlsCAProvider.Certificates() {
+			if ca.NotAfter.Before(deadline) {
+				return fmt.Errorf("CA certificate '%s' expires at %s", ca.Subject.CommonName, ca.NotAfter.Format(time.RFC3339))
+			}
+		}
+	}
+
+	return nil
+}
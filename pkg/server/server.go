@@ -11,7 +11,6 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,22 +32,32 @@ type Server struct {
 	auth      auth.Provider
 
 	// Servers
-	appSrv     *http.Server
-	metricsSrv *http.Server
+	// There can be more than one of each when multiple listen addresses are configured (e.g. TCP + Unix socket)
+	appSrvs     []*http.Server
+	metricsSrvs []*http.Server
 
 	// Method that forces a reload of TLS certificates from disk
 	tlsCertWatchFn tlsCertWatchFn
 
 	// TLS configuration for the app server
 	tlsConfig *tls.Config
+	// CA pool used for mTLS client authentication, if enabled; kept here (rather than only read back from
+	// tlsConfig) because checkTLSExpiry needs the individual CA certificates, which aren't recoverable from a
+	// *x509.CertPool
+	tlsCAProvider *tlsCAProvider
+
+	// Readiness probes run by RouteReadyzHandler
+	healthProbes []healthProbe
 
 	running atomic.Bool
 	wg      sync.WaitGroup
 
-	// Listeners for the app and metrics servers
-	// These can be used for testing without having to start an actual TCP listener
-	appListener     net.Listener
-	metricsListener net.Listener
+	// Listeners for the app and metrics servers, and the specs used to create them
+	// These can be used for testing without having to start actual TCP listeners
+	appListeners       []net.Listener
+	appListenSpecs     []listenSpec
+	metricsListeners   []net.Listener
+	metricsListenSpecs []listenSpec
 
 	// Optional function to add test routes
 	// This is used in testing
@@ -70,6 +79,7 @@ func NewServer(opts NewServerOpts) (*Server, error) {
 	s := &Server{
 		addTestRoutes: opts.addTestRoutes,
 		auth:          opts.Auth,
+		metrics:       &metrics.TFAMetricsImpl{},
 	}
 
 	// Init the object
@@ -104,6 +114,9 @@ func (s *Server) initAppServer(log *zerolog.Logger) (err error) {
 		return fmt.Errorf("failed to load TLS configuration: %w", err)
 	}
 
+	// Build the readiness probe registry, used by RouteReadyzHandler
+	s.initHealthProbes(conf)
+
 	// Create the Gin router and add various middlewares
 	s.appRouter = gin.New()
 	s.appRouter.Use(gin.Recovery())
@@ -113,9 +126,10 @@ func (s *Server) initAppServer(log *zerolog.Logger) (err error) {
 	// Logger middleware that removes the auth code from the URL
 	codeFilterLogMw := s.MiddlewareLoggerMask(regexp.MustCompile(`(\?|&)(code|state|session_state)=([^&]*)`), "$1$2***")
 
-	// Healthz route
-	// This does not follow BasePath
-	s.appRouter.GET("/healthz", gin.WrapF(s.RouteHealthzHandler))
+	// Liveness/readiness routes, following the Kubernetes convention
+	// These do not follow BasePath
+	s.appRouter.GET("/livez", gin.WrapF(s.RouteLivezHandler))
+	s.appRouter.GET("/readyz", gin.WrapF(s.RouteReadyzHandler))
 
 	// Auth routes
 	// For the root route, we add it with and without trailing slash (in case BasePath isn't empty) to avoid Gin setting up a 301 (Permanent) redirect, which causes issues with forward auth
@@ -167,17 +181,19 @@ func (s *Server) Run(ctx context.Context) error {
 		return fmt.Errorf("failed to start app server: %w", err)
 	}
 	defer func() {
-		// Handle graceful shutdown
+		// Handle graceful shutdown of every app listener
 		defer s.wg.Done()
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
-		err := s.appSrv.Shutdown(shutdownCtx)
-		shutdownCancel()
-		if err != nil {
-			// Log the error only (could be context canceled)
-			zerolog.Ctx(ctx).Warn().
-				Err(err).
-				Msg("App server shutdown error")
+		for i, srv := range s.appSrvs {
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				// Log the error only (could be context canceled)
+				zerolog.Ctx(ctx).Warn().
+					Err(err).
+					Msg("App server shutdown error")
+			}
+			s.appListenSpecs[i].cleanup()
 		}
+		shutdownCancel()
 	}()
 
 	// Metrics server
@@ -188,17 +204,19 @@ func (s *Server) Run(ctx context.Context) error {
 			return fmt.Errorf("failed to start metrics server: %w", err)
 		}
 		defer func() {
-			// Handle graceful shutdown
+			// Handle graceful shutdown of every metrics listener
 			defer s.wg.Done()
 			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
-			err := s.metricsSrv.Shutdown(shutdownCtx)
-			shutdownCancel()
-			if err != nil {
-				// Log the error only (could be context canceled)
-				zerolog.Ctx(ctx).Warn().
-					Err(err).
-					Msg("Metrics server shutdown error")
+			for i, srv := range s.metricsSrvs {
+				if err := srv.Shutdown(shutdownCtx); err != nil {
+					// Log the error only (could be context canceled)
+					zerolog.Ctx(ctx).Warn().
+						Err(err).
+						Msg("Metrics server shutdown error")
+				}
+				s.metricsListenSpecs[i].cleanup()
 			}
+			shutdownCancel()
 		}()
 	}
 
@@ -221,52 +239,72 @@ func (s *Server) startAppServer(ctx context.Context) error {
 	cfg := config.Get()
 	log := zerolog.Ctx(ctx)
 
-	// Create the HTTP(S) server
-	s.appSrv = &http.Server{
-		Addr:              net.JoinHostPort(cfg.Bind, strconv.Itoa(cfg.Port)),
-		MaxHeaderBytes:    1 << 20,
-		ReadHeaderTimeout: 10 * time.Second,
+	// Build the list of listen specs if we don't have one already (e.g. from tests)
+	if s.appListenSpecs == nil {
+		var err error
+		s.appListenSpecs, err = parseListenSpecs(cfg.ListenAddresses, cfg.Bind, cfg.Port)
+		if err != nil {
+			return fmt.Errorf("invalid app server listen address: %w", err)
+		}
 	}
-	if s.tlsConfig != nil {
-		// Using TLS
-		s.appSrv.Handler = s.appRouter
-		s.appSrv.TLSConfig = s.tlsConfig
-	} else {
-		// Not using TLS
+
+	// Create the listeners if we don't have them already
+	if s.appListeners == nil {
+		appListeners := make([]net.Listener, len(s.appListenSpecs))
+		for i, spec := range s.appListenSpecs {
+			ln, err := listen(spec)
+			if err != nil {
+				closePartialListeners(appListeners, s.appListenSpecs)
+				return err
+			}
+			appListeners[i] = ln
+		}
+		s.appListeners = appListeners
+	}
+
+	// One http.Server per listener, all sharing the same appRouter handler
+	var handler http.Handler = s.appRouter
+	if s.tlsConfig == nil && len(cfg.TLSNextProtos) == 0 {
+		// Not using TLS, and the operator hasn't explicitly restricted the negotiated protocols
 		// Here we also need to enable HTTP/2 Cleartext
 		h2s := &http2.Server{}
-		s.appSrv.Handler = h2c.NewHandler(s.appRouter, h2s)
+		handler = h2c.NewHandler(s.appRouter, h2s)
 	}
 
-	// Create the listener if we don't have one already
-	if s.appListener == nil {
-		var err error
-		s.appListener, err = net.Listen("tcp", s.appSrv.Addr)
-		if err != nil {
-			return fmt.Errorf("failed to create TCP listener: %w", err)
+	s.appSrvs = make([]*http.Server, len(s.appListenSpecs))
+	for i, spec := range s.appListenSpecs {
+		srv := &http.Server{
+			Handler:           handler,
+			MaxHeaderBytes:    1 << 20,
+			ReadHeaderTimeout: 10 * time.Second,
 		}
-	}
-
-	// Start the HTTP(S) server in a background goroutine
-	log.Info().
-		Str("bind", cfg.Bind).
-		Int("port", cfg.Port).
-		Bool("tls", s.tlsConfig != nil).
-		Msg("App server started")
-	go func() {
-		defer s.appListener.Close()
-
-		// Next call blocks until the server is shut down
-		var srvErr error
 		if s.tlsConfig != nil {
-			srvErr = s.appSrv.ServeTLS(s.appListener, "", "")
-		} else {
-			srvErr = s.appSrv.Serve(s.appListener)
-		}
-		if srvErr != http.ErrServerClosed {
-			log.Fatal().Err(srvErr).Msgf("Error starting app server")
+			srv.TLSConfig = s.tlsConfig
 		}
-	}()
+		s.appSrvs[i] = srv
+
+		log.Info().
+			Str("network", spec.network).
+			Str("address", spec.address).
+			Bool("tls", s.tlsConfig != nil).
+			Msg("App server started")
+
+		ln := s.appListeners[i]
+		go func(srv *http.Server, ln net.Listener) {
+			defer ln.Close()
+
+			// Next call blocks until the server is shut down
+			var srvErr error
+			if s.tlsConfig != nil {
+				srvErr = srv.ServeTLS(ln, "", "")
+			} else {
+				srvErr = srv.Serve(ln)
+			}
+			if srvErr != http.ErrServerClosed {
+				log.Fatal().Err(srvErr).Msgf("Error starting app server")
+			}
+		}(srv, ln)
+	}
 
 	return nil
 }
@@ -277,40 +315,58 @@ func (s *Server) startMetricsServer(ctx context.Context) error {
 
 	// Handler
 	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", s.RouteHealthzHandler)
+	mux.HandleFunc("/livez", s.RouteLivezHandler)
+	mux.HandleFunc("/readyz", s.RouteReadyzHandler)
 	mux.Handle("/metrics", s.metrics.HTTPHandler())
 
-	// Create the HTTP server
-	s.metricsSrv = &http.Server{
-		Addr:              net.JoinHostPort(cfg.MetricsBind, strconv.Itoa(cfg.MetricsPort)),
-		Handler:           mux,
-		MaxHeaderBytes:    1 << 20,
-		ReadHeaderTimeout: 10 * time.Second,
-	}
-
-	// Create the listener if we don't have one already
-	if s.metricsListener == nil {
+	// Build the list of listen specs if we don't have one already (e.g. from tests)
+	if s.metricsListenSpecs == nil {
 		var err error
-		s.metricsListener, err = net.Listen("tcp", s.metricsSrv.Addr)
+		s.metricsListenSpecs, err = parseListenSpecs(cfg.MetricsListenAddresses, cfg.MetricsBind, cfg.MetricsPort)
 		if err != nil {
-			return fmt.Errorf("failed to create TCP listener: %w", err)
+			return fmt.Errorf("invalid metrics server listen address: %w", err)
+		}
+	}
+
+	// Create the listeners if we don't have them already
+	if s.metricsListeners == nil {
+		metricsListeners := make([]net.Listener, len(s.metricsListenSpecs))
+		for i, spec := range s.metricsListenSpecs {
+			ln, err := listen(spec)
+			if err != nil {
+				closePartialListeners(metricsListeners, s.metricsListenSpecs)
+				return err
+			}
+			metricsListeners[i] = ln
 		}
+		s.metricsListeners = metricsListeners
 	}
 
-	// Start the HTTPS server in a background goroutine
-	log.Info().
-		Str("bind", cfg.MetricsBind).
-		Int("port", cfg.MetricsPort).
-		Msg("Metrics server started")
-	go func() {
-		defer s.metricsListener.Close()
-
-		// Next call blocks until the server is shut down
-		srvErr := s.metricsSrv.Serve(s.metricsListener)
-		if srvErr != http.ErrServerClosed {
-			log.Fatal().Err(srvErr).Msgf("Error starting metrics server")
+	s.metricsSrvs = make([]*http.Server, len(s.metricsListenSpecs))
+	for i, spec := range s.metricsListenSpecs {
+		srv := &http.Server{
+			Handler:           mux,
+			MaxHeaderBytes:    1 << 20,
+			ReadHeaderTimeout: 10 * time.Second,
 		}
-	}()
+		s.metricsSrvs[i] = srv
+
+		log.Info().
+			Str("network", spec.network).
+			Str("address", spec.address).
+			Msg("Metrics server started")
+
+		ln := s.metricsListeners[i]
+		go func(srv *http.Server, ln net.Listener) {
+			defer ln.Close()
+
+			// Next call blocks until the server is shut down
+			srvErr := srv.Serve(ln)
+			if srvErr != http.ErrServerClosed {
+				log.Fatal().Err(srvErr).Msgf("Error starting metrics server")
+			}
+		}(srv, ln)
+	}
 
 	return nil
 }
@@ -319,8 +375,31 @@ func (s *Server) startMetricsServer(ctx context.Context) error {
 func (s *Server) loadTLSConfig(log *zerolog.Logger) (tlsConfig *tls.Config, watchFn tlsCertWatchFn, err error) {
 	cfg := config.Get()
 
-	tlsConfig = &tls.Config{
-		MinVersion: minTLSVersion,
+	// Summarize the effective TLS mode once loading has succeeded (including when TLS ends up disabled), so
+	// misconfiguration never surfaces only as silent plaintext HTTP; on error, the returned error is reported instead
+	defer func() {
+		if err != nil {
+			return
+		}
+
+		certSource := "none"
+		switch {
+		case tlsConfig == nil:
+			certSource = "none"
+		case len(cfg.TLSCertificates) > 0:
+			certSource = "tlsCertificates"
+		case cfg.TLSCertPEM != "":
+			certSource = "pem"
+		default:
+			certSource = "file"
+		}
+
+		logTLSSummary(log, certSource, tlsConfig != nil, cfg.TLSClientAuth, watchFn != nil)
+	}()
+
+	tlsConfig = &tls.Config{}
+	if err = applyTLSProtocolOptions(tlsConfig, cfg, log); err != nil {
+		return nil, nil, fmt.Errorf("invalid TLS protocol options: %w", err)
 	}
 
 	// If "tlsPath" is empty, use the folder where the config file is located
@@ -332,10 +411,20 @@ func (s *Server) loadTLSConfig(log *zerolog.Logger) (tlsConfig *tls.Config, watc
 		}
 	}
 
+	// Run a strict validation pass up front so a misconfiguration (a typo'd key, a missing CA when mTLS is
+	// enabled, an unreadable file) is reported in full rather than surfacing later as unexplained plaintext HTTP
+	if err = validateTLSConfig(cfg, tlsPath); err != nil {
+		return nil, nil, fmt.Errorf("invalid TLS configuration: %w", err)
+	}
+
+	// Used to combine the CA pool watcher (if any) with the server cert watcher before returning
+	var caWatchFn tlsCertWatchFn
+
 	// Start by setting the CA certificate and enable mTLS if required
 	if cfg.TLSClientAuth {
 		// Check if we have the actual keys
 		caCert := []byte(cfg.TLSCAPEM)
+		caCertPath := ""
 
 		// If caCert is empty, we need to load the CA certificate from file
 		if len(caCert) > 0 {
@@ -345,7 +434,8 @@ func (s *Server) loadTLSConfig(log *zerolog.Logger) (tlsConfig *tls.Config, watc
 				return nil, nil, errors.New("cannot find a CA certificate, which is required when `tlsClientAuth` is enabled: no path specified in option `tlsPath`, and no config file was loaded")
 			}
 
-			caCert, err = os.ReadFile(filepath.Join(tlsPath, tlsCAFile))
+			caCertPath = filepath.Join(tlsPath, tlsCAFile)
+			caCert, err = os.ReadFile(caCertPath)
 			if err != nil {
 				// This also returns an error if the file doesn't exist
 				// We want to error here as `tlsClientAuth` is true
@@ -357,19 +447,71 @@ func (s *Server) loadTLSConfig(log *zerolog.Logger) (tlsConfig *tls.Config, watc
 				Msg("Loaded CA certificate from disk")
 		}
 
-		caCertPool := x509.NewCertPool()
-		ok := caCertPool.AppendCertsFromPEM(caCert)
-		if !ok {
-			return nil, nil, fmt.Errorf("failed to import CA certificate from PEM found at path '%s'", tlsPath)
+		caProvider, err := newTLSCAProvider(caCert, caCertPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to import CA certificate from PEM found at path '%s': %w", tlsPath, err)
 		}
 
 		// Set ClientAuth to VerifyClientCertIfGiven because not all endpoints we have require mTLS
+		// GetConfigForClient (rather than ClientCAs directly) is used so every handshake picks up a reloaded pool
 		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
-		tlsConfig.ClientCAs = caCertPool
+		tlsConfig.GetConfigForClient = caProvider.GetConfigForClientFn(tlsConfig)
+		s.tlsCAProvider = caProvider
+
+		if caCertPath != "" {
+			caWatchFn = func(ctx context.Context) error {
+				return caProvider.Watch(ctx, zerolog.Ctx(ctx), func(error) {
+					s.metrics.RecordTLSCAReloadFailure()
+				})
+			}
+		}
+
+		// Revocation checking (CRLs) for the mTLS client-auth path
+		if cfg.TLSCRLPath != "" || len(cfg.TLSCRLPEM) > 0 {
+			var crlPaths []string
+			if cfg.TLSCRLPath != "" {
+				crlPaths = []string{cfg.TLSCRLPath}
+			}
+
+			crls, crlErr := newCRLStore(cfg.TLSCRLPEM, crlPaths)
+			if crlErr != nil {
+				return nil, nil, fmt.Errorf("failed to load CRLs: %w", crlErr)
+			}
+
+			tlsConfig.VerifyPeerCertificate = crls.VerifyPeerCertificateFn(func() {
+				s.metrics.RecordTLSRevokedRejection()
+			})
+
+			crlWatchFn := func(ctx context.Context) error {
+				return crls.Watch(ctx, zerolog.Ctx(ctx), func(error) {
+					s.metrics.RecordTLSCAReloadFailure()
+				})
+			}
+			caWatchFn = combineTLSWatchFns(caWatchFn, crlWatchFn)
+
+			log.Debug().Msg("CRL-based revocation checking is enabled for mTLS client certificates")
+		}
 
 		log.Debug().Msg("TLS Client Authentication is enabled for sensitive endpoints")
 	}
 
+	// If one or more SNI-scoped certificates are configured, they take precedence over the single-certificate
+	// options below, allowing this instance to front multiple auth hostnames with different leaf certificates
+	if len(cfg.TLSCertificates) > 0 {
+		selector, err := newSNICertSelector(cfg.TLSCertificates)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load 'tlsCertificates': %w", err)
+		}
+
+		tlsConfig.GetCertificate = selector.GetCertificateFn()
+
+		log.Debug().
+			Int("count", len(cfg.TLSCertificates)).
+			Msg("Loaded SNI-scoped TLS certificates")
+
+		return tlsConfig, combineTLSWatchFns(append(selector.watchFns(), caWatchFn)...), nil
+	}
+
 	// Let's set the server cert and key now
 	// First, check if we have actual keys
 	tlsCert := cfg.TLSCertPEM
@@ -399,15 +541,11 @@ func (s *Server) loadTLSConfig(log *zerolog.Logger) (tlsConfig *tls.Config, watc
 
 		tlsConfig.GetCertificate = provider.GetCertificateFn()
 
-		return tlsConfig, provider.Watch, nil
-	}
-
-	// Assume the values from the config file are PEM-encoded certs and key
-	if tlsCert == "" || tlsKey == "" {
-		// If tlsCert and/or tlsKey is empty, do not use TLS
-		return nil, nil, nil
+		return tlsConfig, combineTLSWatchFns(provider.Watch, caWatchFn), nil
 	}
 
+	// At this point tlsCert and tlsKey are both guaranteed non-empty: the both-empty case was handled above, and
+	// validateTLSConfig already rejected having just one of the two set
 	cert, err := tls.X509KeyPair([]byte(tlsCert), []byte(tlsKey))
 	if err != nil {
 		return nil, nil, fmt.Errorf("failed to parse TLS certificate or key: %w", err)
@@ -416,5 +554,21 @@ func (s *Server) loadTLSConfig(log *zerolog.Logger) (tlsConfig *tls.Config, watc
 
 	log.Debug().Msg("Loaded TLS certificates from PEM values")
 
-	return tlsConfig, nil, nil
+	if len(cert.Certificate) > 0 {
+		warnIfHostnameNotCovered(log, cert.Certificate[0], cfg.Hostname)
+	}
+
+	// Optional OCSP stapling for the server certificate
+	if cfg.TLSOCSPStapling {
+		// enableOCSPStapling updates s.tlsConfig, which is otherwise only assigned once this function returns
+		s.tlsConfig = tlsConfig
+		ocspWatchFn, ocspErr := s.enableOCSPStapling(&cert)
+		if ocspErr != nil {
+			log.Warn().Err(ocspErr).Msg("OCSP stapling could not be enabled; continuing without it")
+		} else {
+			caWatchFn = combineTLSWatchFns(caWatchFn, ocspWatchFn)
+		}
+	}
+
+	return tlsConfig, caWatchFn, nil
 }
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+)
+
+// caReloadFallbackInterval is how often we re-read the CA file from disk even if fsnotify didn't fire an event
+// This is a safety net for Kubernetes secret mounts, which swap a symlinked directory atomically rather than
+// triggering a plain "write" event on the file itself
+const caReloadFallbackInterval = 5 * time.Minute
+
+// tlsCAProvider loads a CA certificate pool used for mTLS client authentication, and optionally keeps it
+// up-to-date by watching the file on disk for changes
+type tlsCAProvider struct {
+	path  string
+	pool  atomic.Pointer[x509.CertPool]
+	certs atomic.Pointer[[]*x509.Certificate]
+}
+
+// newTLSCAProvider creates a tlsCAProvider from a PEM-encoded CA bundle
+// If path is non-empty, the provider can also Watch the file for changes and hot-reload the pool
+func newTLSCAProvider(pemData []byte, path string) (*tlsCAProvider, error) {
+	p := &tlsCAProvider{path: path}
+
+	pool, certs, err := parseCACertPool(pemData)
+	if err != nil {
+		return nil, err
+	}
+	p.pool.Store(pool)
+	p.certs.Store(&certs)
+
+	return p, nil
+}
+
+// parseCACertPool parses a PEM-encoded CA bundle into a cert pool, also returning the individual parsed
+// certificates since *x509.CertPool has no public API to read them back out
+func parseCACertPool(pemData []byte) (*x509.CertPool, []*x509.Certificate, error) {
+	pool := x509.NewCertPool()
+	if ok := pool.AppendCertsFromPEM(pemData); !ok {
+		return nil, nil, errors.New("failed to parse CA certificate(s) from PEM data")
+	}
+
+	var certs []*x509.Certificate
+	rest := pemData
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			certs = append(certs, cert)
+		}
+	}
+
+	return pool, certs, nil
+}
+
+// CertPool returns the current CA certificate pool
+func (p *tlsCAProvider) CertPool() *x509.CertPool {
+	return p.pool.Load()
+}
+
+// Certificates returns the current set of individually-parsed CA certificates, e.g. for expiry checks
+func (p *tlsCAProvider) Certificates() []*x509.Certificate {
+	certs := p.certs.Load()
+	if certs == nil {
+		return nil
+	}
+	return *certs
+}
+
+// reload re-reads the CA bundle from disk and atomically swaps the pool if parsing succeeds
+// If parsing fails, the previous pool is kept and the error is returned to the caller for logging/metrics
+func (p *tlsCAProvider) reload() error {
+	if p.path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read CA certificate file '%s': %w", p.path, err)
+	}
+
+	pool, certs, err := parseCACertPool(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse CA certificate file '%s': %w", p.path, err)
+	}
+
+	p.pool.Store(pool)
+	p.certs.Store(&certs)
+
+	return nil
+}
+
+// Watch watches the CA certificate file for changes, reloading the pool whenever it changes
+// It also re-reads the file on a fixed interval as a fallback, to survive atomic symlink swaps (e.g. Kubernetes
+// secret mounts) that fsnotify may not reliably observe
+func (p *tlsCAProvider) Watch(ctx context.Context, log *zerolog.Logger, onReloadFailure func(error)) error {
+	if p.path == "" {
+		// Nothing to watch; the CA pool was loaded from a static PEM value
+		<-ctx.Done()
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create fsnotify watcher for CA certificate: %w", err)
+	}
+	defer watcher.Close()
+
+	// Watch the containing directory rather than the file itself, so we pick up atomic renames/symlink swaps
+	dir := filepath.Dir(p.path)
+	if err = watcher.Add(dir); err != nil {
+		return fmt.Errorf("failed to watch directory '%s' for CA certificate changes: %w", dir, err)
+	}
+
+	ticker := time.NewTicker(caReloadFallbackInterval)
+	defer ticker.Stop()
+
+	reload := func() {
+		if rErr := p.reload(); rErr != nil {
+			log.Warn().
+				Err(rErr).
+				Str("path", p.path).
+				Msg("Failed to reload CA certificate pool; keeping previous pool")
+			if onReloadFailure != nil {
+				onReloadFailure(rErr)
+			}
+			return
+		}
+		log.Info().
+			Str("path", p.path).
+			Msg("Reloaded CA certificate pool")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reload()
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if filepath.Clean(event.Name) == filepath.Clean(p.path) {
+				reload()
+			}
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn().Err(watchErr).Msg("Error watching CA certificate file for changes")
+		}
+	}
+}
+
+// combineTLSWatchFns merges one or more tlsCertWatchFn into a single one that runs all of them concurrently and
+// returns as soon as any of them returns (whether with an error or because the context was canceled)
+// Nil watch functions are ignored, so callers don't need to special-case the "nothing to watch" case
+func combineTLSWatchFns(fns ...tlsCertWatchFn) tlsCertWatchFn {
+	active := make([]tlsCertWatchFn, 0, len(fns))
+	for _, fn := range fns {
+		if fn != nil {
+			active = append(active, fn)
+		}
+	}
+
+	switch len(active) {
+	case 0:
+		return nil
+	case 1:
+		return active[0]
+	}
+
+	return func(ctx context.Context) error {
+		errCh := make(chan error, len(active))
+		for _, fn := range active {
+			go func(fn tlsCertWatchFn) {
+				errCh <- fn(ctx)
+			}(fn)
+		}
+
+		// Return as soon as one watcher stops; the others will stop too once ctx is canceled by the caller
+		return <-errCh
+	}
+}
+
+// GetConfigForClientFn returns a function suitable for tls.Config.GetConfigForClient that clones base and sets
+// ClientCAs to the current CA certificate pool, so every handshake picks up the latest reloaded pool
+func (p *tlsCAProvider) GetConfigForClientFn(base *tls.Config) func(*tls.ClientHelloInfo) (*tls.Config, error) {
+	return func(_ *tls.ClientHelloInfo) (*tls.Config, error) {
+		cfg := base.Clone()
+		cfg.ClientCAs = p.CertPool()
+		return cfg, nil
+	}
+}
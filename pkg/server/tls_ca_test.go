@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// certToPEM PEM-encodes a parsed certificate, for building test fixtures
+func certToPEM(cert *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+}
+
+func TestParseCACertPool(t *testing.T) {
+	ca, _ := newTestCA(t)
+	caPEM := certToPEM(ca)
+
+	t.Run("valid PEM", func(t *testing.T) {
+		pool, certs, err := parseCACertPool(caPEM)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if pool == nil {
+			t.Error("expected a non-nil pool")
+		}
+		if len(certs) != 1 || certs[0].SerialNumber.Cmp(ca.SerialNumber) != 0 {
+			t.Errorf("expected exactly the parsed CA certificate, got %+v", certs)
+		}
+	})
+
+	t.Run("malformed PEM", func(t *testing.T) {
+		if _, _, err := parseCACertPool([]byte("not a certificate")); err == nil {
+			t.Error("expected an error for malformed PEM data, got nil")
+		}
+	})
+}
+
+func TestTLSCAProviderReload(t *testing.T) {
+	ca1, _ := newTestCA(t)
+	ca2, _ := newTestCA(t)
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, certToPEM(ca1), 0o600); err != nil {
+		t.Fatalf("failed to write test CA file: %v", err)
+	}
+
+	provider, err := newTLSCAProvider(certToPEM(ca1), path)
+	if err != nil {
+		t.Fatalf("unexpected error creating provider: %v", err)
+	}
+	if len(provider.Certificates()) != 1 || !provider.Certificates()[0].Equal(ca1) {
+		t.Fatalf("expected the pool to contain ca1 initially")
+	}
+
+	// A good replacement file swaps the pool
+	if err := os.WriteFile(path, certToPEM(ca2), 0o600); err != nil {
+		t.Fatalf("failed to rewrite test CA file: %v", err)
+	}
+	if err := provider.reload(); err != nil {
+		t.Fatalf("unexpected error reloading a valid replacement: %v", err)
+	}
+	if len(provider.Certificates()) != 1 || !provider.Certificates()[0].Equal(ca2) {
+		t.Fatalf("expected the pool to have swapped to ca2")
+	}
+
+	// A bad replacement file keeps the previous (ca2) pool and returns an error
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("failed to rewrite test CA file: %v", err)
+	}
+	if err := provider.reload(); err == nil {
+		t.Fatal("expected an error reloading a malformed replacement")
+	}
+	if len(provider.Certificates()) != 1 || !provider.Certificates()[0].Equal(ca2) {
+		t.Fatalf("expected the pool to still contain ca2 after a failed reload")
+	}
+}
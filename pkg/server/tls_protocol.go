@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+
+	"github.com/rs/zerolog"
+
+	"github.com/italypaleale/traefik-forward-auth/pkg/config"
+)
+
+// defaultTLSNextProtos is used when `tlsNextProtos` isn't set, preserving the existing behavior of negotiating
+// HTTP/2 before falling back to HTTP/1.1
+var defaultTLSNextProtos = []string{"h2", "http/1.1"}
+
+// tlsVersionsByName maps the version strings accepted in config to their tls.VersionTLSxx constants
+var tlsVersionsByName = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsCurvesByName maps the curve names accepted in config to their tls.CurveID constants
+var tlsCurvesByName = map[string]tls.CurveID{
+	"X25519": tls.X25519,
+	"P256":   tls.CurveP256,
+	"P384":   tls.CurveP384,
+	"P521":   tls.CurveP521,
+}
+
+// applyTLSProtocolOptions applies the configurable protocol floor/ceiling, cipher suites, curve preferences and
+// ALPN protocols to tlsConfig, falling back to the existing defaults when an option isn't set
+func applyTLSProtocolOptions(tlsConfig *tls.Config, cfg *config.Config, log *zerolog.Logger) error {
+	tlsConfig.MinVersion = minTLSVersion
+	if cfg.TLSMinVersion != "" {
+		v, err := parseTLSVersion(cfg.TLSMinVersion)
+		if err != nil {
+			return fmt.Errorf("invalid 'tlsMinVersion': %w", err)
+		}
+		tlsConfig.MinVersion = v
+	}
+
+	if cfg.TLSMaxVersion != "" {
+		v, err := parseTLSVersion(cfg.TLSMaxVersion)
+		if err != nil {
+			return fmt.Errorf("invalid 'tlsMaxVersion': %w", err)
+		}
+		tlsConfig.MaxVersion = v
+	}
+
+	if tlsConfig.MaxVersion != 0 && tlsConfig.MinVersion > tlsConfig.MaxVersion {
+		return fmt.Errorf("'tlsMinVersion' (%s) cannot be greater than 'tlsMaxVersion' (%s)", cfg.TLSMinVersion, cfg.TLSMaxVersion)
+	}
+
+	if len(cfg.TLSCipherSuites) > 0 {
+		suites, err := parseCipherSuites(cfg.TLSCipherSuites)
+		if err != nil {
+			return err
+		}
+		tlsConfig.CipherSuites = suites
+	}
+
+	if len(cfg.TLSCurvePreferences) > 0 {
+		curves, err := parseCurvePreferences(cfg.TLSCurvePreferences)
+		if err != nil {
+			return err
+		}
+		tlsConfig.CurvePreferences = curves
+	}
+
+	nextProtos := defaultTLSNextProtos
+	if len(cfg.TLSNextProtos) > 0 {
+		nextProtos = cfg.TLSNextProtos
+	}
+	tlsConfig.NextProtos = nextProtos
+
+	log.Info().
+		Uint16("minVersion", tlsConfig.MinVersion).
+		Uint16("maxVersion", tlsConfig.MaxVersion).
+		Int("cipherSuites", len(tlsConfig.CipherSuites)).
+		Strs("nextProtos", tlsConfig.NextProtos).
+		Msg("Effective TLS protocol configuration")
+
+	return nil
+}
+
+// parseTLSVersion parses a version string such as "1.2" or "1.3" into its tls.VersionTLSxx constant
+func parseTLSVersion(s string) (uint16, error) {
+	v, ok := tlsVersionsByName[s]
+	if !ok {
+		return 0, fmt.Errorf("unsupported TLS version '%s'", s)
+	}
+	return v, nil
+}
+
+// parseCipherSuites resolves a list of IANA cipher suite names to their tls package IDs, restricted to the
+// "safe" subset returned by tls.CipherSuites() (i.e. excluding tls.InsecureCipherSuites())
+func parseCipherSuites(names []string) ([]uint16, error) {
+	available := make(map[string]uint16, len(tls.CipherSuites()))
+	for _, s := range tls.CipherSuites() {
+		available[s.Name] = s.ID
+	}
+
+	suites := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := available[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite '%s'", name)
+		}
+		suites = append(suites, id)
+	}
+
+	return suites, nil
+}
+
+// parseCurvePreferences resolves a list of curve names to their tls.CurveID constants
+func parseCurvePreferences(names []string) ([]tls.CurveID, error) {
+	curves := make([]tls.CurveID, 0, len(names))
+	for _, name := range names {
+		id, ok := tlsCurvesByName[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown TLS curve '%s'", name)
+		}
+		curves = append(curves, id)
+	}
+
+	return curves, nil
+}
@@ -0,0 +1,21 @@
+package server
+
+import "testing"
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := parseCipherSuites([]string{"TLS_AES_128_GCM_SHA256"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(suites) != 1 {
+		t.Fatalf("expected 1 cipher suite, got %d", len(suites))
+	}
+
+	if _, err := parseCipherSuites([]string{"TLS_RSA_WITH_RC4_128_SHA"}); err == nil {
+		t.Error("expected an error for an insecure cipher suite, got nil")
+	}
+
+	if _, err := parseCipherSuites([]string{"NOT_A_REAL_SUITE"}); err == nil {
+		t.Error("expected an error for an unknown cipher suite, got nil")
+	}
+}
@@ -0,0 +1,329 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog"
+	"golang.org/x/crypto/ocsp"
+)
+
+// ocspRefreshInterval is how often we re-fetch the OCSP staple for the server certificate
+const ocspRefreshInterval = 1 * time.Hour
+
+// revokedKey identifies a revoked certificate by its issuer's subject key identifier and serial number, both
+// hex-encoded, which together are unique enough for our purposes without needing full issuer DN comparison
+type revokedKey struct {
+	issuerSKID string
+	serial     string
+}
+
+// crlStore holds the set of revoked certificates parsed from one or more CRLs, indexed by issuer SKID so that
+// VerifyPeerCertificate can reject a handshake in O(1) per certificate in the chain
+type crlStore struct {
+	paths   []string
+	pemVals []string
+	revoked atomic.Pointer[map[string]map[string]struct{}]
+}
+
+// newCRLStore loads CRLs from the given PEM values and/or file paths and builds the initial revoked-serial index
+func newCRLStore(pemVals []string, paths []string) (*crlStore, error) {
+	s := &crlStore{paths: paths, pemVals: pemVals}
+	if err := s.reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// reload re-parses all configured CRLs and atomically swaps the revoked-serial index
+// On error, the previous index is kept so a single malformed CRL (mid-rotation, for example) doesn't leave the
+// server without any revocation data
+func (s *crlStore) reload() error {
+	index := make(map[string]map[string]struct{})
+
+	addCRL := func(der []byte, source string) error {
+		crl, err := x509.ParseRevocationList(der)
+		if err != nil {
+			return fmt.Errorf("failed to parse CRL from '%s': %w", source, err)
+		}
+
+		skid := hex.EncodeToString(crl.AuthorityKeyId)
+		set, ok := index[skid]
+		if !ok {
+			set = make(map[string]struct{})
+			index[skid] = set
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			set[hex.EncodeToString(entry.SerialNumber.Bytes())] = struct{}{}
+		}
+
+		return nil
+	}
+
+	for _, pemVal := range s.pemVals {
+		block, _ := pem.Decode([]byte(pemVal))
+		if block == nil {
+			return errors.New("failed to decode CRL PEM value")
+		}
+		if err := addCRL(block.Bytes, "PEM value"); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range s.paths {
+		der, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read CRL file '%s': %w", path, err)
+		}
+		if block, _ := pem.Decode(der); block != nil {
+			der = block.Bytes
+		}
+		if err := addCRL(der, path); err != nil {
+			return err
+		}
+	}
+
+	s.revoked.Store(&index)
+
+	return nil
+}
+
+// isRevoked returns true if a certificate with the given issuer SKID and serial number has been revoked
+func (s *crlStore) isRevoked(issuerSKID, serial []byte) bool {
+	index := s.revoked.Load()
+	if index == nil {
+		return false
+	}
+	set, ok := (*index)[hex.EncodeToString(issuerSKID)]
+	if !ok {
+		return false
+	}
+	_, revoked := set[hex.EncodeToString(serial)]
+	return revoked
+}
+
+// Watch watches every configured CRL file for changes, reloading the revoked-serial index whenever one changes,
+// with a periodic fallback re-read for the same reasons as tlsCAProvider.Watch
+func (s *crlStore) Watch(ctx context.Context, log *zerolog.Logger, onReloadFailure func(error)) error {
+	if len(s.paths) == 0 {
+		<-ctx.Done()
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create fsnotify watcher for CRLs: %w", err)
+	}
+	defer watcher.Close()
+
+	dirs := make(map[string]struct{})
+	for _, path := range s.paths {
+		dirs[filepath.Dir(path)] = struct{}{}
+	}
+	for dir := range dirs {
+		if err = watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch directory '%s' for CRL changes: %w", dir, err)
+		}
+	}
+
+	ticker := time.NewTicker(caReloadFallbackInterval)
+	defer ticker.Stop()
+
+	reload := func() {
+		if rErr := s.reload(); rErr != nil {
+			log.Warn().Err(rErr).Msg("Failed to reload CRLs; keeping previous revocation list")
+			if onReloadFailure != nil {
+				onReloadFailure(rErr)
+			}
+			return
+		}
+		log.Info().Msg("Reloaded CRLs")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			reload()
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			reload()
+		case watchErr, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Warn().Err(watchErr).Msg("Error watching CRL files for changes")
+		}
+	}
+}
+
+// VerifyPeerCertificateFn returns a function suitable for tls.Config.VerifyPeerCertificate that rejects the
+// handshake if any certificate in any verified chain has been revoked
+func (s *crlStore) VerifyPeerCertificateFn(onRevokedRejection func()) func([][]byte, [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		for _, chain := range verifiedChains {
+			for i, cert := range chain {
+				if i+1 >= len(chain) {
+					// No issuer in this chain to key the revocation lookup on
+					continue
+				}
+				issuer := chain[i+1]
+				if s.isRevoked(issuer.SubjectKeyId, cert.SerialNumber.Bytes()) {
+					if onRevokedRejection != nil {
+						onRevokedRejection()
+					}
+					return fmt.Errorf("certificate with serial '%s' has been revoked", cert.SerialNumber.String())
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// ocspStapler periodically fetches an OCSP response for the server certificate from its issuer's OCSP responder
+// and keeps it available to be attached to tls.Certificate.OCSPStaple
+type ocspStapler struct {
+	leaf         *x509.Certificate
+	issuer       *x509.Certificate
+	responderURL string
+
+	staple atomic.Pointer[[]byte]
+}
+
+// newOCSPStapler creates a stapler for the given leaf/issuer pair, using the OCSP responder URL embedded in the
+// leaf certificate
+func newOCSPStapler(leaf, issuer *x509.Certificate) (*ocspStapler, error) {
+	if len(leaf.OCSPServer) == 0 {
+		return nil, errors.New("certificate does not specify an OCSP responder URL")
+	}
+	return &ocspStapler{leaf: leaf, issuer: issuer, responderURL: leaf.OCSPServer[0]}, nil
+}
+
+// Staple returns the current OCSP staple, or nil if none has been fetched yet
+func (o *ocspStapler) Staple() []byte {
+	p := o.staple.Load()
+	if p == nil {
+		return nil
+	}
+	return *p
+}
+
+// refresh fetches a fresh OCSP response and stores it if the response indicates the certificate is still good
+func (o *ocspStapler) refresh(ctx context.Context) error {
+	req, err := ocsp.CreateRequest(o.leaf, o.issuer, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.responderURL, bytes.NewReader(req))
+	if err != nil {
+		return fmt.Errorf("failed to create OCSP HTTP request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/ocsp-request")
+
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to fetch OCSP response: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read OCSP response: %w", err)
+	}
+
+	parsed, err := ocsp.ParseResponseForCert(body, o.leaf, o.issuer)
+	if err != nil {
+		return fmt.Errorf("failed to parse OCSP response: %w", err)
+	}
+	if parsed.Status != ocsp.Good {
+		return fmt.Errorf("OCSP responder returned non-good status %d", parsed.Status)
+	}
+
+	o.staple.Store(&body)
+
+	return nil
+}
+
+// enableOCSPStapling sets up an ocspStapler for the given server certificate and points tlsConfig.GetCertificate
+// at a closure that returns the certificate with the latest fetched staple attached, returning a tlsCertWatchFn
+// that keeps the staple refreshed
+// tlsConfig.Certificates is cleared as part of this: per crypto/tls's Config.getCertificate, GetCertificate is
+// only consulted when len(Certificates) == 0 or the client sent SNI, so leaving Certificates set would silently
+// serve the original, non-stapled certificate to any client that connects without SNI
+func (s *Server) enableOCSPStapling(cert *tls.Certificate) (tlsCertWatchFn, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, errors.New("certificate chain does not include an issuer certificate, required for OCSP stapling")
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse leaf certificate: %w", err)
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer certificate: %w", err)
+	}
+
+	stapler, err := newOCSPStapler(leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tlsConfig.GetCertificate = func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		withStaple := *cert
+		withStaple.OCSPStaple = stapler.Staple()
+		return &withStaple, nil
+	}
+	s.tlsConfig.Certificates = nil
+
+	return func(ctx context.Context) error {
+		return stapler.Watch(ctx, zerolog.Ctx(ctx), func(error) {
+			s.metrics.RecordTLSOCSPRefreshFailure()
+		})
+	}, nil
+}
+
+// Watch periodically refreshes the OCSP staple until ctx is canceled
+func (o *ocspStapler) Watch(ctx context.Context, log *zerolog.Logger, onRefreshFailure func(error)) error {
+	// Fetch once up front so the first handshakes can staple
+	if err := o.refresh(ctx); err != nil {
+		log.Warn().Err(err).Msg("Failed to fetch initial OCSP staple")
+		if onRefreshFailure != nil {
+			onRefreshFailure(err)
+		}
+	}
+
+	ticker := time.NewTicker(ocspRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := o.refresh(ctx); err != nil {
+				log.Warn().Err(err).Msg("Failed to refresh OCSP staple")
+				if onRefreshFailure != nil {
+					onRefreshFailure(err)
+				}
+			}
+		}
+	}
+}
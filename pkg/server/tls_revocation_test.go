@@ -0,0 +1,259 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// newTestCA creates a self-signed CA certificate suitable for signing CRLs and leaf certificates in tests
+func newTestCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate CA key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		SubjectKeyId:          []byte{0x01, 0x02, 0x03, 0x04},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create CA certificate: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse CA certificate: %v", err)
+	}
+
+	return cert, key
+}
+
+// newTestLeaf creates a leaf certificate issued by ca, with the given serial number
+func newTestLeaf(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial *big.Int, ocspServer string) *x509.Certificate {
+	t.Helper()
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate leaf key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if ocspServer != "" {
+		template.OCSPServer = []string{ocspServer}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create leaf certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse leaf certificate: %v", err)
+	}
+
+	return leaf
+}
+
+// buildCRLPEM builds a PEM-encoded CRL signed by ca, revoking the given serials
+func buildCRLPEM(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, revoked ...*big.Int) string {
+	t.Helper()
+
+	entries := make([]x509.RevocationListEntry, len(revoked))
+	for i, serial := range revoked {
+		entries[i] = x509.RevocationListEntry{SerialNumber: serial, RevocationTime: time.Now()}
+	}
+
+	template := &x509.RevocationList{
+		Number:                    big.NewInt(1),
+		ThisUpdate:                time.Now(),
+		NextUpdate:                time.Now().Add(time.Hour),
+		RevokedCertificateEntries: entries,
+	}
+
+	der, err := x509.CreateRevocationList(rand.Reader, template, ca, caKey)
+	if err != nil {
+		t.Fatalf("failed to create CRL: %v", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der}))
+}
+
+func TestCRLStoreIsRevoked(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	revokedSerial := big.NewInt(42)
+	crlPEM := buildCRLPEM(t, ca, caKey, revokedSerial)
+
+	store, err := newCRLStore([]string{crlPEM}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !store.isRevoked(ca.SubjectKeyId, revokedSerial.Bytes()) {
+		t.Error("expected the listed serial to be revoked")
+	}
+	if store.isRevoked(ca.SubjectKeyId, big.NewInt(7).Bytes()) {
+		t.Error("expected an unlisted serial to not be revoked")
+	}
+	if store.isRevoked([]byte{0xFF}, revokedSerial.Bytes()) {
+		t.Error("expected the serial to not match under an unrelated issuer SKID")
+	}
+}
+
+func TestCRLStoreReloadKeepsPreviousIndexOnError(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	revokedSerial := big.NewInt(99)
+	crlPEM := buildCRLPEM(t, ca, caKey, revokedSerial)
+
+	store, err := newCRLStore([]string{crlPEM}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Corrupt the configured PEM value in place and attempt a reload
+	store.pemVals = []string{"not a valid CRL"}
+	if err := store.reload(); err == nil {
+		t.Fatal("expected reload to fail on a malformed CRL")
+	}
+
+	if !store.isRevoked(ca.SubjectKeyId, revokedSerial.Bytes()) {
+		t.Error("expected the previously-loaded revoked serial to remain revoked after a failed reload")
+	}
+}
+
+func TestCRLStoreVerifyPeerCertificateFn(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	revokedSerial := big.NewInt(7)
+	crlPEM := buildCRLPEM(t, ca, caKey, revokedSerial)
+
+	store, err := newCRLStore([]string{crlPEM}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var rejected bool
+	verify := store.VerifyPeerCertificateFn(func() { rejected = true })
+
+	revokedLeaf := newTestLeaf(t, ca, caKey, revokedSerial, "")
+	if err := verify(nil, [][]*x509.Certificate{{revokedLeaf, ca}}); err == nil {
+		t.Error("expected an error for a revoked certificate")
+	}
+	if !rejected {
+		t.Error("expected onRevokedRejection to be called for a revoked certificate")
+	}
+
+	rejected = false
+	okLeaf := newTestLeaf(t, ca, caKey, big.NewInt(8), "")
+	if err := verify(nil, [][]*x509.Certificate{{okLeaf, ca}}); err != nil {
+		t.Errorf("unexpected error for a non-revoked certificate: %v", err)
+	}
+	if rejected {
+		t.Error("onRevokedRejection should not be called for a non-revoked certificate")
+	}
+}
+
+func TestOCSPStaplerRefresh(t *testing.T) {
+	ca, caKey := newTestCA(t)
+	leaf := newTestLeaf(t, ca, caKey, big.NewInt(123), "http://placeholder.invalid")
+
+	t.Run("good response is stapled and the request is well-formed", func(t *testing.T) {
+		var gotContentType string
+		var gotSerial *big.Int
+
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotContentType = r.Header.Get("Content-Type")
+			body, _ := io.ReadAll(r.Body)
+			if req, parseErr := ocsp.ParseRequest(body); parseErr == nil {
+				gotSerial = req.SerialNumber
+			}
+
+			respBody, respErr := ocsp.CreateResponse(ca, ca, ocsp.Response{
+				Status:       ocsp.Good,
+				SerialNumber: leaf.SerialNumber,
+				ThisUpdate:   time.Now(),
+				NextUpdate:   time.Now().Add(time.Hour),
+			}, caKey)
+			if respErr != nil {
+				t.Fatalf("failed to build OCSP response: %v", respErr)
+			}
+			w.Header().Set("Content-Type", "application/ocsp-response")
+			_, _ = w.Write(respBody)
+		}))
+		defer srv.Close()
+
+		stapler, err := newOCSPStapler(leaf, ca)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stapler.responderURL = srv.URL
+
+		if err := stapler.refresh(context.Background()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(stapler.Staple()) == 0 {
+			t.Error("expected a non-empty staple after a good response")
+		}
+		if gotContentType != "application/ocsp-request" {
+			t.Errorf("expected Content-Type 'application/ocsp-request', got %q", gotContentType)
+		}
+		if gotSerial == nil || gotSerial.Cmp(leaf.SerialNumber) != 0 {
+			t.Errorf("expected request serial %v, got %v", leaf.SerialNumber, gotSerial)
+		}
+	})
+
+	t.Run("non-good response is rejected", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			respBody, respErr := ocsp.CreateResponse(ca, ca, ocsp.Response{
+				Status:       ocsp.Revoked,
+				SerialNumber: leaf.SerialNumber,
+				ThisUpdate:   time.Now(),
+				NextUpdate:   time.Now().Add(time.Hour),
+				RevokedAt:    time.Now(),
+			}, caKey)
+			if respErr != nil {
+				t.Fatalf("failed to build OCSP response: %v", respErr)
+			}
+			w.Header().Set("Content-Type", "application/ocsp-response")
+			_, _ = w.Write(respBody)
+		}))
+		defer srv.Close()
+
+		stapler, err := newOCSPStapler(leaf, ca)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		stapler.responderURL = srv.URL
+
+		if err := stapler.refresh(context.Background()); err == nil {
+			t.Error("expected an error for a non-good OCSP status")
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/italypaleale/traefik-forward-auth/pkg/config"
+)
+
+// sniCertEntry is a single server certificate configured via `tlsCertificates`, optionally scoped to one or
+// more SNI host names
+type sniCertEntry struct {
+	// names are the explicit SNI host names this entry should be served for (may include a leading "*." wildcard
+	// label); if empty, the names are derived from the certificate's own DNS SANs
+	names []string
+	// isDefault marks the entry to fall back to when no other entry matches the requested SNI name, or when the
+	// client doesn't send one at all (e.g. plain TCP/HTTP clients)
+	isDefault bool
+
+	// Exactly one of provider or staticCert is set, mirroring the single-certificate loading logic: a file path
+	// is reloadable via tlsCertProvider, a PEM value is not
+	provider   *tlsCertProvider
+	staticCert *tls.Certificate
+}
+
+// certificate returns the current leaf certificate for this entry
+func (e *sniCertEntry) certificate() (*tls.Certificate, error) {
+	if e.provider != nil {
+		return e.provider.GetCertificateFn()(nil)
+	}
+	return e.staticCert, nil
+}
+
+// matches returns true if this entry should be used to serve the given SNI server name
+func (e *sniCertEntry) matches(serverName string) bool {
+	for _, n := range e.names {
+		if matchHostname(n, serverName) {
+			return true
+		}
+	}
+	return false
+}
+
+// sniCertSelector picks the right certificate for a TLS handshake based on the client's requested SNI host name,
+// supporting multiple independently-rotatable certificates
+type sniCertSelector struct {
+	entries      []*sniCertEntry
+	defaultEntry *sniCertEntry
+}
+
+// GetCertificateFn returns a function suitable for tls.Config.GetCertificate
+func (sel *sniCertSelector) GetCertificateFn() func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		if hello != nil && hello.ServerName != "" {
+			for _, e := range sel.entries {
+				if e.matches(hello.ServerName) {
+					return e.certificate()
+				}
+			}
+		}
+
+		if sel.defaultEntry != nil {
+			return sel.defaultEntry.certificate()
+		}
+
+		return nil, fmt.Errorf("no TLS certificate configured for SNI name '%s' and no default certificate set", hello.ServerName)
+	}
+}
+
+// watchFns returns the non-nil watch functions of every reloadable (file-backed) entry, so callers can combine
+// them with combineTLSWatchFns
+func (sel *sniCertSelector) watchFns() []tlsCertWatchFn {
+	fns := make([]tlsCertWatchFn, 0, len(sel.entries))
+	for _, e := range sel.entries {
+		if e.provider != nil {
+			fns = append(fns, e.provider.Watch)
+		}
+	}
+	return fns
+}
+
+// newSNICertSelector builds a sniCertSelector from the `tlsCertificates` config entries
+// Each entry provides either a PEM-encoded cert/key pair or a directory path (reloadable, like the single-cert
+// case); if no explicit SNI names are given, they're derived from the leaf certificate's own DNS SANs
+func newSNICertSelector(certs []config.TLSCertificateEntry) (*sniCertSelector, error) {
+	sel := &sniCertSelector{entries: make([]*sniCertEntry, 0, len(certs))}
+
+	for i, c := range certs {
+		entry := &sniCertEntry{names: c.SNINames, isDefault: c.Default}
+
+		switch {
+		case c.CertPEM != "" || c.KeyPEM != "":
+			if c.CertPEM == "" || c.KeyPEM == "" {
+				return nil, fmt.Errorf("tlsCertificates[%d]: both 'certPEM' and 'keyPEM' must be set together", i)
+			}
+			cert, err := tls.X509KeyPair([]byte(c.CertPEM), []byte(c.KeyPEM))
+			if err != nil {
+				return nil, fmt.Errorf("tlsCertificates[%d]: failed to parse certificate or key: %w", i, err)
+			}
+			entry.staticCert = &cert
+
+		case c.Path != "":
+			provider, err := newTLSCertProvider(c.Path)
+			if err != nil {
+				return nil, fmt.Errorf("tlsCertificates[%d]: failed to load certificate from path '%s': %w", i, c.Path, err)
+			}
+			if provider == nil {
+				return nil, fmt.Errorf("tlsCertificates[%d]: no certificate found at path '%s'", i, c.Path)
+			}
+			entry.provider = provider
+
+		default:
+			return nil, fmt.Errorf("tlsCertificates[%d]: one of 'certPEM'/'keyPEM' or 'path' must be set", i)
+		}
+
+		if len(entry.names) == 0 {
+			cert, err := entry.certificate()
+			if err != nil {
+				return nil, fmt.Errorf("tlsCertificates[%d]: failed to read certificate to derive SNI names: %w", i, err)
+			}
+			leaf, err := x509.ParseCertificate(cert.Certificate[0])
+			if err != nil {
+				return nil, fmt.Errorf("tlsCertificates[%d]: failed to parse leaf certificate: %w", i, err)
+			}
+			entry.names = leaf.DNSNames
+		}
+
+		if entry.isDefault {
+			if sel.defaultEntry != nil {
+				return nil, errors.New("tlsCertificates: only one entry may be marked as 'default'")
+			}
+			sel.defaultEntry = entry
+		}
+
+		sel.entries = append(sel.entries, entry)
+	}
+
+	// If none was explicitly marked default, fall back to the first entry, mirroring how the single-certificate
+	// configuration is always used regardless of SNI
+	if sel.defaultEntry == nil && len(sel.entries) > 0 {
+		sel.defaultEntry = sel.entries[0]
+	}
+
+	return sel, nil
+}
+
+// matchHostname reports whether host matches pattern, which may be an exact host name or a single-label wildcard
+// such as "*.example.com" (matching "auth.example.com" but not "a.auth.example.com" or "example.com" itself)
+func matchHostname(pattern, host string) bool {
+	pattern = strings.ToLower(strings.TrimSuffix(pattern, "."))
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	if pattern == host {
+		return true
+	}
+
+	label, rest, ok := strings.Cut(pattern, ".")
+	if !ok || label != "*" {
+		return false
+	}
+
+	hostLabel, hostRest, ok := strings.Cut(host, ".")
+	if !ok || hostLabel == "" {
+		return false
+	}
+
+	return hostRest == rest
+}
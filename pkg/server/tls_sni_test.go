@@ -0,0 +1,25 @@
+package server
+
+import "testing"
+
+func TestMatchHostname(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{"auth.example.com", "auth.example.com", true},
+		{"auth.example.com", "AUTH.example.com.", true},
+		{"*.example.com", "auth.example.com", true},
+		{"*.example.com", "a.auth.example.com", false},
+		{"*.example.com", "example.com", false},
+		{"auth.example.com", "other.example.com", false},
+		{"*.example.com", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := matchHostname(tt.pattern, tt.host); got != tt.want {
+			t.Errorf("matchHostname(%q, %q) = %v, want %v", tt.pattern, tt.host, got, tt.want)
+		}
+	}
+}
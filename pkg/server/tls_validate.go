@@ -0,0 +1,97 @@
+package server
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/rs/zerolog"
+
+	"github.com/italypaleale/traefik-forward-auth/pkg/config"
+)
+
+// validateTLSConfig performs a strict up-front validation pass over the TLS options, collecting every issue
+// found (rather than stopping at the first one) so a misconfiguration is reported in full instead of surfacing
+// later as unexplained plaintext HTTP
+// tlsPath is the already-resolved directory to look for on-disk certs/keys/CA in (see loadTLSConfig)
+func validateTLSConfig(cfg *config.Config, tlsPath string) error {
+	var errs []error
+
+	// A cert/key pair must be configured together, not just one of the two
+	if (cfg.TLSCertPEM != "") != (cfg.TLSKeyPEM != "") {
+		errs = append(errs, errors.New("'tlsCertPEM' and 'tlsKeyPEM' must either both be set, or both be empty"))
+	}
+
+	// If mTLS is enabled, we must end up with a usable, non-empty CA pool
+	if cfg.TLSClientAuth {
+		if err := validateTLSCAAvailable(cfg, tlsPath); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// The CA file, if present under tlsPath, must at least be readable; a missing file is tolerated by the
+	// branches that treat it as "feature not configured", but a present-and-unreadable file is always a
+	// misconfiguration worth failing loudly on
+	if tlsPath != "" {
+		path := filepath.Join(tlsPath, tlsCAFile)
+		if _, err := os.ReadFile(path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("file '%s' is present but could not be read: %w", path, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateTLSCAAvailable returns an error if `tlsClientAuth` is enabled but no usable CA certificate can be
+// found, from either a PEM value or a file under tlsPath
+func validateTLSCAAvailable(cfg *config.Config, tlsPath string) error {
+	if cfg.TLSCAPEM != "" {
+		return nil
+	}
+
+	if tlsPath == "" {
+		return errors.New("'tlsClientAuth' is enabled but no CA certificate is configured ('tlsCAPEM' is empty and no 'tlsPath' was set)")
+	}
+
+	path := filepath.Join(tlsPath, tlsCAFile)
+	if _, err := os.ReadFile(path); err != nil {
+		return fmt.Errorf("'tlsClientAuth' is enabled but the CA certificate could not be loaded from '%s': %w", path, err)
+	}
+
+	return nil
+}
+
+// warnIfHostnameNotCovered logs (but does not fail on) a leaf certificate whose SANs don't include cfg.Hostname,
+// since this is very often a sign of a misconfiguration that would otherwise only surface as a confusing TLS
+// error in the client
+func warnIfHostnameNotCovered(log *zerolog.Logger, certDER []byte, hostname string) {
+	if hostname == "" || len(certDER) == 0 {
+		return
+	}
+
+	leaf, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		return
+	}
+
+	if err := leaf.VerifyHostname(hostname); err != nil {
+		log.Warn().
+			Err(err).
+			Str("hostname", hostname).
+			Strs("certificateSANs", leaf.DNSNames).
+			Msg("The configured TLS certificate does not appear to cover 'hostname'")
+	}
+}
+
+// logTLSSummary emits a one-line summary of the effective TLS configuration at startup, so the cert source,
+// mTLS status and reload watcher state are always visible rather than inferred from silence
+func logTLSSummary(log *zerolog.Logger, certSource string, tlsEnabled bool, mTLSEnabled bool, watcherActive bool) {
+	log.Info().
+		Bool("tlsEnabled", tlsEnabled).
+		Str("certSource", certSource).
+		Bool("mTLSEnabled", mTLSEnabled).
+		Bool("reloadWatcherActive", watcherActive).
+		Msg("Effective TLS mode")
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/italypaleale/traefik-forward-auth/pkg/config"
+)
+
+func TestValidateTLSConfig(t *testing.T) {
+	t.Run("valid empty config", func(t *testing.T) {
+		if err := validateTLSConfig(&config.Config{}, ""); err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("cert without key", func(t *testing.T) {
+		err := validateTLSConfig(&config.Config{TLSCertPEM: "cert"}, "")
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("client auth without CA", func(t *testing.T) {
+		err := validateTLSConfig(&config.Config{TLSClientAuth: true}, "")
+		if err == nil {
+			t.Error("expected an error, got nil")
+		}
+	})
+
+	t.Run("client auth with CA PEM", func(t *testing.T) {
+		err := validateTLSConfig(&config.Config{TLSClientAuth: true, TLSCAPEM: "ca"}, "")
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+
+	t.Run("aggregates multiple errors", func(t *testing.T) {
+		err := validateTLSConfig(&config.Config{TLSCertPEM: "cert", TLSClientAuth: true}, "")
+		if err == nil {
+			t.Fatal("expected an error, got nil")
+		}
+		joined, ok := err.(interface{ Unwrap() []error })
+		if !ok || len(joined.Unwrap()) != 2 {
+			t.Errorf("expected both the cert/key and the CA errors to be aggregated, got: %v", err)
+		}
+	})
+
+	t.Run("unreadable CA file under tlsPath", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, tlsCAFile)
+		// A directory where a file is expected can never be successfully read as one, regardless of permissions
+		if err := os.Mkdir(path, 0o755); err != nil {
+			t.Fatalf("failed to set up test fixture: %v", err)
+		}
+
+		err := validateTLSConfig(&config.Config{}, dir)
+		if err == nil {
+			t.Error("expected an error for an unreadable CA file, got nil")
+		}
+	})
+}